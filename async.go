@@ -0,0 +1,84 @@
+package rotatelogs
+
+import (
+	"fmt"
+	"os"
+)
+
+// asyncMsg is what travels down rl.asyncCh. A non-nil sync channel
+// marks a Sync() barrier rather than data to write: by the time it's
+// pulled off the channel, every write enqueued ahead of it has
+// already been processed, since the channel is FIFO.
+type asyncMsg struct {
+	data []byte
+	sync chan struct{}
+}
+
+// WithAsync creates a new Option that makes Write enqueue a copy of
+// each []byte onto a buffered channel of size bufSize, serviced by a
+// single background goroutine that performs the actual
+// getTargetWriter().Write. This keeps application goroutines from
+// blocking on os.OpenFile while a rotation is in flight, as long as
+// the channel has room; once bufSize writes are pending, Write waits
+// for the background goroutine to take one.
+func WithAsync(bufSize int) Option {
+	return OptionFn(func(rl *RotateLogs) error {
+		rl.async = true
+		rl.asyncBufSize = bufSize
+		return nil
+	})
+}
+
+// startAsyncWriter spins up the background goroutine that services
+// rl.asyncCh. It is a no-op unless WithAsync was passed to New.
+func (rl *RotateLogs) startAsyncWriter() {
+	if !rl.async {
+		return
+	}
+	rl.asyncCh = make(chan asyncMsg, rl.asyncBufSize)
+	rl.asyncWG.Add(1)
+	go rl.asyncWriteLoop()
+}
+
+func (rl *RotateLogs) asyncWriteLoop() {
+	defer rl.asyncWG.Done()
+	for msg := range rl.asyncCh {
+		if msg.sync != nil {
+			close(msg.sync)
+			continue
+		}
+
+		rl.mutex.Lock()
+		_, err := rl.writeLocked(msg.data)
+		rl.mutex.Unlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write: %s\n", err)
+		}
+	}
+}
+
+// flushAsync blocks until every write enqueued so far has been
+// handed to the active file.
+func (rl *RotateLogs) flushAsync() {
+	done := make(chan struct{})
+	rl.asyncCh <- asyncMsg{sync: done}
+	<-done
+}
+
+// Sync flushes any buffered asynchronous writes and then fsyncs the
+// currently active file, giving callers using WithAsync a barrier to
+// wait on when they need to know their data has actually reached
+// disk.
+func (rl *RotateLogs) Sync() error {
+	if rl.async {
+		rl.flushAsync()
+	}
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.outFh == nil {
+		return nil
+	}
+	return rl.outFh.Sync()
+}
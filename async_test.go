@@ -0,0 +1,77 @@
+package rotatelogs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAsyncSyncWaitsForWrites checks that WithAsync doesn't lose
+// writes, and that Sync actually blocks until everything written so
+// far has landed on disk rather than returning early.
+func TestAsyncSyncWaitsForWrites(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app.log")
+
+	rl, err := New(pattern, WithAsync(8))
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	defer rl.Close()
+
+	const line = "async line\n"
+	const n = 50
+	for i := 0; i < n; i++ {
+		if _, err := rl.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %s", err)
+		}
+	}
+
+	if err := rl.Sync(); err != nil {
+		t.Fatalf("Sync: %s", err)
+	}
+
+	b, err := os.ReadFile(pattern)
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err)
+	}
+
+	want := n * len(line)
+	if len(b) != want {
+		t.Errorf("expected %d bytes on disk immediately after Sync, got %d", want, len(b))
+	}
+}
+
+// TestAsyncCloseDrainsQueue checks that Close doesn't drop writes
+// still sitting in the async queue.
+func TestAsyncCloseDrainsQueue(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app.log")
+
+	rl, err := New(pattern, WithAsync(8))
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	const line = "async line\n"
+	const n = 50
+	for i := 0; i < n; i++ {
+		if _, err := rl.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %s", err)
+		}
+	}
+
+	if err := rl.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	b, err := os.ReadFile(pattern)
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err)
+	}
+
+	want := n * len(line)
+	if len(b) != want {
+		t.Errorf("expected %d bytes on disk after Close, got %d", want, len(b))
+	}
+}
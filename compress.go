@@ -0,0 +1,124 @@
+package rotatelogs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// gzipSuffix is appended to a file name once it has been
+// compressed by the background compression worker.
+const gzipSuffix = ".gz"
+
+// compressQueueSize is the number of pending files the
+// compression worker will buffer before enqueueCompress starts
+// dropping files rather than block the write path.
+const compressQueueSize = 16
+
+// WithCompress creates a new Option that, when enabled, gzips
+// files as they rotate out of the active slot and removes the
+// uncompressed original. Compression runs on a background
+// goroutine so it never blocks the write path.
+func WithCompress(enabled bool) Option {
+	return OptionFn(func(rl *RotateLogs) error {
+		rl.compress = enabled
+		return nil
+	})
+}
+
+// startCompressWorker spins up the background goroutine that
+// services rl.compressCh. It is a no-op unless compression is
+// enabled.
+func (rl *RotateLogs) startCompressWorker() {
+	if !rl.compress {
+		return
+	}
+	rl.compressCh = make(chan string, compressQueueSize)
+	rl.compressWG.Add(1)
+	go rl.compressWorker()
+}
+
+// compressWorker drains the compression queue and gzips each
+// file it receives.
+func (rl *RotateLogs) compressWorker() {
+	defer rl.compressWG.Done()
+	for fn := range rl.compressCh {
+		if err := compressFile(fn); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to compress %s: %s\n", fn, err)
+			continue
+		}
+		rl.fireEvent(FileCompressedEvent{SourceFile: fn, CompressedFile: fn + gzipSuffix})
+	}
+}
+
+// enqueueCompress schedules fn for background compression. It
+// skips files that are already compressed, and is a no-op unless
+// WithCompress(true) was passed to New. Callers are expected to
+// only enqueue files that are no longer the active write target.
+//
+// This is called with rl.mutex held (directly from Write, or from
+// the async writer goroutine), so the send must never block: if the
+// worker has fallen behind and the queue is full, drop the file and
+// log it rather than stall every subsequent write.
+func (rl *RotateLogs) enqueueCompress(fn string) {
+	if !rl.compress || fn == "" || strings.HasSuffix(fn, gzipSuffix) {
+		return
+	}
+
+	rl.shutdownMu.RLock()
+	defer rl.shutdownMu.RUnlock()
+	if rl.closed {
+		return
+	}
+
+	select {
+	case rl.compressCh <- fn:
+	default:
+		fmt.Fprintf(os.Stderr, "compress queue full, dropping %s\n", fn)
+	}
+}
+
+// compressFile gzips fn into fn+".gz" and removes fn once the
+// compressed copy has been written out successfully.
+func compressFile(fn string) (err error) {
+	if fn == "" || strings.HasSuffix(fn, gzipSuffix) {
+		return nil
+	}
+
+	src, err := os.Open(fn)
+	if err != nil {
+		return errors.Wrap(err, `failed to open file to compress`)
+	}
+	defer src.Close()
+
+	dstName := fn + gzipSuffix
+	dst, err := os.OpenFile(dstName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, `failed to create compressed file`)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(dstName)
+		}
+	}()
+
+	gw := gzip.NewWriter(dst)
+	if _, err = io.Copy(gw, src); err != nil {
+		gw.Close()
+		dst.Close()
+		return errors.Wrap(err, `failed to write compressed file`)
+	}
+	if err = gw.Close(); err != nil {
+		dst.Close()
+		return errors.Wrap(err, `failed to close gzip writer`)
+	}
+	if err = dst.Close(); err != nil {
+		return errors.Wrap(err, `failed to close compressed file`)
+	}
+
+	return os.Remove(fn)
+}
@@ -0,0 +1,87 @@
+package rotatelogs
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCompressOnRotate checks that WithCompress gzips a file once it
+// rotates out of the active slot and removes the uncompressed
+// original, preserving its content.
+func TestCompressOnRotate(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app.log")
+
+	rl, err := New(pattern,
+		WithMaxFileSize(10),
+		WithRotateMode(ModeRename),
+		WithCompress(true),
+	)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	const payload = "hello world, this line is long enough to rotate\n"
+	if _, err := rl.Write([]byte(payload)); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	// Crossing maxFileSize only rotates on the *next* write, so a
+	// second write is what actually triggers renameActiveFile.
+	if _, err := rl.Write([]byte(payload)); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	if err := rl.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	matches, err := filepath.Glob(pattern + ".*" + gzipSuffix)
+	if err != nil {
+		t.Fatalf("glob: %s", err)
+	}
+
+	// Close waits for the compression worker to drain, so the .gz
+	// file should already be there; poll briefly anyway in case it
+	// is not visible yet.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(matches) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		matches, err = filepath.Glob(pattern + ".*" + gzipSuffix)
+		if err != nil {
+			t.Fatalf("glob: %s", err)
+		}
+	}
+
+	if len(matches) != 1 {
+		t.Fatalf("expected exactly one compressed backup, got %v", matches)
+	}
+	compressed := matches[0]
+	uncompressed := compressed[:len(compressed)-len(gzipSuffix)]
+
+	if _, err := os.Stat(uncompressed); !os.IsNotExist(err) {
+		t.Errorf("expected uncompressed backup %s to be removed, stat err: %v", uncompressed, err)
+	}
+
+	f, err := os.Open(compressed)
+	if err != nil {
+		t.Fatalf("open compressed file: %s", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %s", err)
+	}
+	defer gr.Close()
+
+	got, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatalf("read gzip contents: %s", err)
+	}
+	if string(got) != payload {
+		t.Errorf("expected compressed contents %q, got %q", payload, string(got))
+	}
+}
@@ -0,0 +1,68 @@
+package rotatelogs
+
+// Event is the common interface implemented by every notification a
+// Handler can receive.
+type Event interface {
+	event()
+}
+
+// FileRotatedEvent is fired once the active file has been swapped
+// out for a new one.
+type FileRotatedEvent struct {
+	PreviousFile string
+	CurrentFile  string
+}
+
+func (FileRotatedEvent) event() {}
+
+// FileRemovedEvent is fired after a file is purged from disk, e.g.
+// because it was older than maxAge or rotationCount was exceeded.
+type FileRemovedEvent struct {
+	Path   string
+	Reason string
+}
+
+func (FileRemovedEvent) event() {}
+
+// FileCompressedEvent is fired after a rotated-out file has been
+// gzipped in place (see WithCompress).
+type FileCompressedEvent struct {
+	SourceFile     string
+	CompressedFile string
+}
+
+func (FileCompressedEvent) event() {}
+
+// Handler receives rotation/purge notifications, e.g. to increment a
+// metric or ship the previous file off to S3. Handle is called
+// synchronously from the code path that produced the event, so
+// implementations must not block.
+type Handler interface {
+	Handle(Event)
+}
+
+// HandlerFunc adapts a plain function to a Handler.
+type HandlerFunc func(Event)
+
+// Handle calls f(e).
+func (f HandlerFunc) Handle(e Event) {
+	f(e)
+}
+
+// WithHandler creates a new Option that registers h to receive
+// FileRotatedEvent, FileRemovedEvent and FileCompressedEvent
+// notifications as they happen.
+func WithHandler(h Handler) Option {
+	return OptionFn(func(rl *RotateLogs) error {
+		rl.eventHandler = h
+		return nil
+	})
+}
+
+// fireEvent delivers e to the configured handler, if any.
+func (rl *RotateLogs) fireEvent(e Event) {
+	if rl.eventHandler == nil {
+		return
+	}
+	rl.eventHandler.Handle(e)
+}
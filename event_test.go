@@ -0,0 +1,120 @@
+package rotatelogs
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestEventsFireOnRotate checks that a registered Handler observes a
+// FileRotatedEvent for the rotations that happen, each naming the
+// stable active file as CurrentFile and a distinct backup as
+// PreviousFile.
+func TestEventsFireOnRotate(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app.log")
+
+	var mu sync.Mutex
+	var events []FileRotatedEvent
+	handler := HandlerFunc(func(e Event) {
+		if re, ok := e.(FileRotatedEvent); ok {
+			mu.Lock()
+			events = append(events, re)
+			mu.Unlock()
+		}
+	})
+
+	rl, err := New(pattern,
+		WithMaxFileSize(10),
+		WithRotateMode(ModeRename),
+		WithHandler(handler),
+	)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	defer rl.Close()
+
+	const line = "a line long enough to cross maxFileSize\n"
+	for i := 0; i < 3; i++ {
+		if _, err := rl.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %s", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) < 2 {
+		t.Fatalf("expected at least 2 FileRotatedEvent, got %d: %+v", len(events), events)
+	}
+	for _, e := range events {
+		if e.CurrentFile != pattern {
+			t.Errorf("expected CurrentFile %q, got %q", pattern, e.CurrentFile)
+		}
+		if e.PreviousFile == "" || e.PreviousFile == pattern {
+			t.Errorf("expected PreviousFile to be a distinct backup name, got %q", e.PreviousFile)
+		}
+	}
+}
+
+// TestEventsFireOnRemove checks that purging a backup past
+// rotationCount fires a FileRemovedEvent naming the removed file.
+func TestEventsFireOnRemove(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app.log")
+
+	var mu sync.Mutex
+	var removed []FileRemovedEvent
+	handler := HandlerFunc(func(e Event) {
+		if re, ok := e.(FileRemovedEvent); ok {
+			mu.Lock()
+			removed = append(removed, re)
+			mu.Unlock()
+		}
+	})
+
+	rl, err := New(pattern,
+		WithMaxAge(0),
+		WithRotationCount(1),
+		WithMaxFileSize(10),
+		WithRotateMode(ModeRename),
+		WithHandler(handler),
+	)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	defer rl.Close()
+
+	const line = "a line long enough to cross maxFileSize\n"
+	for i := 0; i < 6; i++ {
+		if _, err := rl.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %s", err)
+		}
+		// purgeRenamedBackups unlinks on its own goroutine; give it a
+		// moment to run between rotations so later rotations see a
+		// clean slate of backups to count against rotationCount.
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(removed)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(removed) == 0 {
+		t.Fatalf("expected at least one FileRemovedEvent, got none")
+	}
+	for _, e := range removed {
+		if e.Reason != "rotation-count" {
+			t.Errorf("expected reason %q, got %q", "rotation-count", e.Reason)
+		}
+	}
+}
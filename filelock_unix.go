@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package rotatelogs
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(fh *os.File) error {
+	return syscall.Flock(int(fh.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(fh *os.File) error {
+	return syscall.Flock(int(fh.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package rotatelogs
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func lockFile(fh *os.File) error {
+	var ol windows.Overlapped
+	return windows.LockFileEx(windows.Handle(fh.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+}
+
+func unlockFile(fh *os.File) error {
+	var ol windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(fh.Fd()), 0, 1, 0, &ol)
+}
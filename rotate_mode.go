@@ -0,0 +1,166 @@
+package rotatelogs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// RotateMode controls how maxFileSize-triggered rotation names the
+// active and rotated-out files.
+type RotateMode int
+
+const (
+	// ModeCreate is the legacy behavior: every time the active file
+	// fills up, a brand new timestamp-suffixed file name is
+	// generated and written to next, so "the active file" is a
+	// moving target. This is the default, so existing users aren't
+	// broken by upgrading.
+	ModeCreate RotateMode = iota
+	// ModeRename keeps the active file under a single stable,
+	// pattern-derived name -- so external tools can `tail -F` it,
+	// or a symlink can point at it, without noticing rotation -- and
+	// renames it to a timestamped backup once it exceeds
+	// maxFileSize. This is the model lumberjack and gookit/slog's
+	// rotatefile use.
+	ModeRename
+)
+
+// WithRotateMode creates a new Option that selects how size-based
+// rotation (see WithMaxFileSize) names files.
+func WithRotateMode(mode RotateMode) Option {
+	return OptionFn(func(rl *RotateLogs) error {
+		rl.rotateMode = mode
+		return nil
+	})
+}
+
+// getTargetWriterRenameMode implements the ModeRename path: the
+// active file always lives at the pattern's literal name, and
+// rl.rotateRule (consulted with rl.curSize, updated in Write rather
+// than stat'd on every call) decides when it's time to rename it out
+// of the way. Routing through rl.rotateRule here, rather than
+// comparing rl.curSize to rl.maxFileSize directly, means a custom
+// WithRotateRule is honored even when combined with WithRotateMode.
+func (rl *RotateLogs) getTargetWriterRenameMode() (io.Writer, error) {
+	now := rl.clock.Now()
+	filename := rl.pattern.FormatString(now)
+
+	if rl.outFh != nil && rl.curFn == filename {
+		if !rl.rotateRule.ShallRotate(filename, rl.curSize, now) {
+			return rl.outFh, nil
+		}
+		if err := rl.renameActiveFile(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to rotate: %s\n", err)
+		}
+	}
+
+	fh, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Errorf("failed to open file %s: %s", filename, err)
+	}
+
+	var size int64
+	if info, statErr := fh.Stat(); statErr == nil {
+		size = info.Size()
+	}
+
+	previousFn := rl.curFn
+	if rl.outFh != nil {
+		rl.outFh.Close()
+	}
+	rl.outFh = fh
+	rl.curFn = filename
+	rl.curSize = size
+
+	if previousFn != "" && previousFn != filename {
+		rl.fireEvent(FileRotatedEvent{PreviousFile: previousFn, CurrentFile: filename})
+	}
+
+	return fh, nil
+}
+
+// renameActiveFile moves filename out of the way to a timestamped
+// backup, so getTargetWriterRenameMode can open a fresh, empty file
+// back at filename.
+func (rl *RotateLogs) renameActiveFile(filename string) error {
+	// Hold the same advisory lock rotate() uses, so two processes
+	// sharing this pattern never rename the active file out from
+	// under each other.
+	unlock, err := rl.acquireRotateLock(filename)
+	if err != nil {
+		return errors.Wrap(err, `failed to acquire rotate lock`)
+	}
+	defer unlock()
+
+	// BackupFileName only has second-level resolution, so two
+	// rotations landing in the same second -- easy to hit under
+	// WithMultiProcess, where several processes can each cross
+	// maxFileSize within the same tick -- would otherwise pick the
+	// same name and os.Rename would silently clobber the earlier
+	// backup. The lock above serializes renames across processes, so
+	// it's safe to keep disambiguating here until we land on a name
+	// nothing is using yet.
+	backup := rl.rotateRule.BackupFileName(filename, rl.clock.Now())
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.%d", rl.rotateRule.BackupFileName(filename, rl.clock.Now()), i)
+	}
+
+	if rl.outFh != nil {
+		rl.outFh.Close()
+		rl.outFh = nil
+	}
+
+	if err := os.Rename(filename, backup); err != nil {
+		return errors.Wrap(err, `failed to rename active file to backup`)
+	}
+
+	rl.fireEvent(FileRotatedEvent{PreviousFile: backup, CurrentFile: filename})
+	rl.enqueueCompress(backup)
+	rl.purgeRenamedBackups(filename)
+
+	return nil
+}
+
+// purgeRenamedBackups removes backups of filename that purgeCandidates
+// -- the same maxAge/rotationCount trim rotate() applies -- decides
+// are no longer wanted. Unlike rotate(), it can't glob rl.globPattern
+// to find its backups: that pattern may be a literal name with no
+// strftime placeholders (the common case under WithRotateMode, since
+// the active file always lives at the pattern's literal name), and
+// filepath.Glob only matches the pattern itself, never the
+// timestamp-suffixed names BackupFileName derives from it. Globbing
+// for filename+".*" instead finds every backup (and, since compressed
+// backups keep the same prefix with a ".gz" suffix, those too)
+// regardless of what the original pattern looked like.
+//
+// Called with rl.acquireRotateLock(filename) already held by
+// renameActiveFile, so it must not try to take that lock again --
+// unlike rotate(), which is never called while that lock is held.
+func (rl *RotateLogs) purgeRenamedBackups(filename string) {
+	matches, err := filepath.Glob(filename + ".*")
+	if err != nil {
+		return
+	}
+
+	toUnlink := rl.purgeCandidates(matches, filename)
+	if len(toUnlink) <= 0 {
+		return
+	}
+
+	reason := rl.purgeReason()
+	go func() {
+		// unlink files on a separate goroutine, same as rotate().
+		for _, path := range toUnlink {
+			if err := os.Remove(path); err == nil {
+				rl.fireEvent(FileRemovedEvent{Path: path, Reason: reason})
+			}
+		}
+	}()
+}
@@ -0,0 +1,218 @@
+package rotatelogs
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// RotateRule decides when the currently active log file should be
+// rotated out, what the rotated-out file should be renamed to, and
+// which files already on disk are no longer current and may be
+// purged. Built-in policies (DailyRule, HourlyRule, SizeRule) cover
+// the time- and size-based rotation this package has always
+// supported; CompositeRule lets several of them be combined, and
+// WithRotateRule lets callers drop in something else entirely.
+type RotateRule interface {
+	// ShallRotate reports whether curFile, whose current size is
+	// curSize, should be rotated out as of now.
+	ShallRotate(curFile string, curSize int64, now time.Time) bool
+	// BackupFileName returns the file name that should be used once
+	// curFile (formatted here as base, the pattern applied to now)
+	// is rotated out.
+	BackupFileName(base string, now time.Time) string
+	// OutdatedFiles returns the subset of files matching globPattern
+	// that this rule is willing to consider for purging. rotate()
+	// still applies the maxAge/rotationCount cutoff on top of this.
+	OutdatedFiles(globPattern string) []string
+}
+
+func globOutdatedFiles(globPattern string) []string {
+	matches, err := filepath.Glob(globPattern)
+	if err != nil {
+		return nil
+	}
+	return matches
+}
+
+// DailyRule rotates once every 24 hours, at a boundary shifted by
+// diff hours away from midnight (see WithZone).
+type DailyRule struct {
+	diff   int
+	format func(time.Time) string
+}
+
+// NewDailyRule creates a DailyRule that rotates at midnight shifted
+// by diff hours, naming the rotated file via format.
+func NewDailyRule(diff int, format func(time.Time) string) *DailyRule {
+	return &DailyRule{diff: diff, format: format}
+}
+
+func (r *DailyRule) bucketStart(now time.Time) time.Time {
+	if now.Hour() >= r.diff {
+		return now.Add(-1 * time.Duration(now.Hour()-r.diff) * time.Hour)
+	}
+	return now.Add(time.Duration(r.diff-now.Hour()) * time.Hour)
+}
+
+// ShallRotate reports whether curFile is no longer the file for
+// today's bucket; curSize is unused since DailyRule only rotates on
+// time.
+func (r *DailyRule) ShallRotate(curFile string, curSize int64, now time.Time) bool {
+	return r.format(r.bucketStart(now)) != curFile
+}
+
+// BackupFileName ignores base and derives the name directly from
+// now's bucket, so the result is stable for the whole day.
+func (r *DailyRule) BackupFileName(base string, now time.Time) string {
+	return r.format(r.bucketStart(now))
+}
+
+func (r *DailyRule) OutdatedFiles(globPattern string) []string {
+	return globOutdatedFiles(globPattern)
+}
+
+// HourlyRule rotates once every period, as given to NewHourlyRule. It is
+// also used internally to back WithRotationTime for any duration
+// other than the 24-hour default handled by DailyRule.
+type HourlyRule struct {
+	period time.Duration
+	format func(time.Time) string
+}
+
+// NewHourlyRule creates an HourlyRule that rotates every period,
+// naming the rotated file via format.
+func NewHourlyRule(period time.Duration, format func(time.Time) string) *HourlyRule {
+	return &HourlyRule{period: period, format: format}
+}
+
+func (r *HourlyRule) bucketStart(now time.Time) time.Time {
+	diff := time.Duration(now.UnixNano()) % r.period
+	return now.Add(-1 * diff)
+}
+
+func (r *HourlyRule) ShallRotate(curFile string, curSize int64, now time.Time) bool {
+	return r.format(r.bucketStart(now)) != curFile
+}
+
+func (r *HourlyRule) BackupFileName(base string, now time.Time) string {
+	return r.format(r.bucketStart(now))
+}
+
+func (r *HourlyRule) OutdatedFiles(globPattern string) []string {
+	return globOutdatedFiles(globPattern)
+}
+
+// SizeRule rotates once the active file reaches maxSize bytes. The
+// rotated-out file keeps base (the pattern applied to the rotation
+// time) with a timestamp appended, since unlike Daily/HourlyRule the
+// same base name can be written to many times before it fills up.
+type SizeRule struct {
+	maxSize int64
+}
+
+// NewSizeRule creates a SizeRule that rotates once a file reaches
+// maxSize bytes.
+func NewSizeRule(maxSize int64) *SizeRule {
+	return &SizeRule{maxSize: maxSize}
+}
+
+func (r *SizeRule) ShallRotate(curFile string, curSize int64, now time.Time) bool {
+	return curSize >= r.maxSize
+}
+
+func (r *SizeRule) BackupFileName(base string, now time.Time) string {
+	return genNameByTime(base)
+}
+
+func (r *SizeRule) OutdatedFiles(globPattern string) []string {
+	return globOutdatedFiles(globPattern)
+}
+
+// CompositeRule ORs several rules together: a rotation happens as
+// soon as any one of them wants it to, e.g. "rotate at midnight UTC
+// or at 500MB, whichever comes first".
+type CompositeRule struct {
+	rules []RotateRule
+	// last remembers which sub-rule triggered the most recent
+	// ShallRotate call that returned true, so a following
+	// BackupFileName call (genFilename and the ModeRename path call
+	// ShallRotate before it, under rl.mutex) defers to that rule's
+	// naming convention. Before any sub-rule has fired -- e.g. the
+	// first genFilename call, which skips ShallRotate -- the first
+	// rule's naming is used.
+	last RotateRule
+}
+
+// NewCompositeRule creates a CompositeRule that rotates whenever any
+// of rules does.
+func NewCompositeRule(rules ...RotateRule) *CompositeRule {
+	return &CompositeRule{rules: rules}
+}
+
+func (r *CompositeRule) ShallRotate(curFile string, curSize int64, now time.Time) bool {
+	for _, sub := range r.rules {
+		if sub.ShallRotate(curFile, curSize, now) {
+			r.last = sub
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CompositeRule) BackupFileName(base string, now time.Time) string {
+	if r.last != nil {
+		return r.last.BackupFileName(base, now)
+	}
+	if len(r.rules) > 0 {
+		return r.rules[0].BackupFileName(base, now)
+	}
+	return base
+}
+
+func (r *CompositeRule) OutdatedFiles(globPattern string) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, sub := range r.rules {
+		for _, fn := range sub.OutdatedFiles(globPattern) {
+			if _, ok := seen[fn]; ok {
+				continue
+			}
+			seen[fn] = struct{}{}
+			out = append(out, fn)
+		}
+	}
+	return out
+}
+
+// WithRotateRule creates a new Option that overrides the built-in
+// rotation policy derived from WithRotationTime/WithMaxFileSize with
+// a custom RotateRule, e.g. a CompositeRule combining a DailyRule
+// and a SizeRule so whichever fires first wins.
+func WithRotateRule(rule RotateRule) Option {
+	return OptionFn(func(rl *RotateLogs) error {
+		rl.rotateRule = rule
+		return nil
+	})
+}
+
+// effectiveRule returns the RotateRule in effect. check() always sets
+// rl.rotateRule before New returns -- to whatever WithRotateRule
+// supplied, or else to the rule buildDefaultRule derives from
+// WithRotationTime/WithMaxFileSize -- so by the time any caller here
+// can run, this is just an accessor.
+func (rl *RotateLogs) effectiveRule() RotateRule {
+	return rl.rotateRule
+}
+
+// buildDefaultRule derives the RotateRule implied by the legacy
+// WithRotationTime/WithMaxFileSize/WithZone fields. It's only called
+// from check(), and only when WithRotateRule wasn't used.
+func (rl *RotateLogs) buildDefaultRule() RotateRule {
+	if rl.maxFileSize > 0 {
+		return NewSizeRule(rl.maxFileSize)
+	}
+	if rl.rotationTime == 24*time.Hour {
+		return NewDailyRule(rl.diff, rl.pattern.FormatString)
+	}
+	return NewHourlyRule(rl.rotationTime, rl.pattern.FormatString)
+}
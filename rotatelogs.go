@@ -8,23 +8,15 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
-	"path/filepath"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	strftime "github.com/wlibo666/go-strftime"
 )
 
-const (
-	minFileSize   = 1024 * 1024
-	checkInterval = 60
-)
-
 func (c clockFn) Now() time.Time {
 	return c()
 }
@@ -92,7 +84,13 @@ func WithZone(zone int) Option {
 }
 
 // WithRotationTime creates a new Option that sets the
-// time between rotation.
+// time between rotation. This is a thin wrapper around the
+// DailyRule/HourlyRule RotateRule; use WithRotateRule directly for
+// anything more specific.
+//
+// rl.rotationTime is only recorded here: the actual DailyRule/
+// HourlyRule is built in check(), once every Option has run, since a
+// later WithZone changes which rule this one needs.
 func WithRotationTime(d time.Duration) Option {
 	return OptionFn(func(rl *RotateLogs) error {
 		rl.rotationTime = d
@@ -113,19 +111,33 @@ func WithRotationCount(n int) Option {
 	})
 }
 
-// 指定单个文件大小限制
+// 指定单个文件大小限制，内部由 SizeRule 实现
+//
+// Like WithRotationTime, this is a thin wrapper: rl.maxFileSize is
+// only recorded here, and check() turns it into a *SizeRule once
+// every Option has had a chance to run.
 func WithMaxFileSize(maxSize int64) Option {
 	return OptionFn(func(rl *RotateLogs) error {
 		rl.maxFileSize = maxSize
-		rl.lastCheckTime = time.Now().Unix()
 		return nil
 	})
 }
 
+// check finalizes options that depend on more than one field, after
+// every Option has run.
 func (rl *RotateLogs) check() {
 	if rl.maxFileSize > 0 {
 		rl.linkName = ""
 	}
+	// buildDefaultRule derives the DailyRule/HourlyRule/SizeRule
+	// implied by WithRotationTime/WithMaxFileSize if WithRotateRule
+	// wasn't used; pinning it to rl.rotateRule here means every later
+	// lookup (genFilename, rotate, getTargetWriterRenameMode) consults
+	// the same RotateRule instead of three parallel implementations of
+	// "should we rotate".
+	if rl.rotateRule == nil {
+		rl.rotateRule = rl.buildDefaultRule()
+	}
 }
 
 // New creates a new RotateLogs object. A log filename pattern
@@ -153,126 +165,95 @@ func New(pattern string, options ...Option) (*RotateLogs, error) {
 		opt.Configure(&rl)
 	}
 	rl.check()
+	rl.startCompressWorker()
+	rl.startAsyncWriter()
 	return &rl, nil
 }
 
-func removeOldFile(fileName string, maxAge int64) error {
-	// 加文件锁
-	lockfn := fileName + `_lock`
-	fh, err := os.OpenFile(lockfn, os.O_CREATE|os.O_EXCL, 0644)
-	if err != nil {
-		// Can't lock, just return
-		return err
-	}
-
-	var guard cleanupGuard
-	guard.fn = func() {
-		fh.Close()
-		os.Remove(lockfn)
-	}
-	defer guard.Run()
-
-	// 遍历文件夹，删除过期文件
-	basePath := path.Dir(fileName)
-	if basePath == fileName {
-		basePath = "./"
-	}
-
-	filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
-		if strings.Contains(path, fileName) && time.Now().UnixNano()-info.ModTime().UnixNano() > maxAge {
-			os.Remove(path)
-		}
-		return nil
-	})
-	return nil
-}
-
 func genNameByTime(file string) string {
 	now := time.Now()
 	return fmt.Sprintf("%s.%04d%02d%02d%02d%02d%02d", file, now.Year(), now.Month(), now.Day(),
 		now.Hour(), now.Minute(), now.Second())
 }
 
-func (rl *RotateLogs) genFileNameWithSizeLimit(fileName string) string {
-	now := time.Now()
-	rl.lastCheckTime = now.Unix()
-	info, err := os.Stat(fileName)
-	if err != nil {
-		return genNameByTime(fileName)
-	}
-	if info.Size() < rl.maxFileSize {
-		return fileName
-	}
-
-	// 生成新文件名称
-	tmpFile := ""
-	sp := strings.Split(fileName, ".")
-	timeSuffix := sp[len(sp)-1]
-	// 时间后缀长度为14： 年4 月2 日2 时2 分2 秒2
-	if len(timeSuffix) != 14 {
-		tmpFile = fileName
-	} else {
-		_, err := strconv.Atoi(timeSuffix)
-		if err != nil {
-			tmpFile = fileName
-		} else {
-			tmpFile = strings.Join(sp[:len(sp)-1], ".")
-		}
-	}
-	go removeOldFile(tmpFile, int64(rl.maxAge))
-
-	return genNameByTime(tmpFile)
-}
-
+// genFilename drives rotation purely through rl.rotateRule, which by
+// check() time is always set -- either the rule WithRotateRule
+// supplied, or the one implied by WithRotationTime/WithMaxFileSize.
+// It consults rl.curSize rather than stat'ing rl.curFn: writeLocked
+// keeps curSize current on every write (and getTargetWriter seeds it
+// from disk whenever it opens a file), so a SizeRule doesn't cost a
+// stat per write the way it would if size were read fresh each time.
 func (rl *RotateLogs) genFilename() string {
-	var t time.Time
 	now := rl.clock.Now()
 
-	if rl.rotationTime == 24*time.Hour {
-		if now.Hour() >= rl.diff {
-			t = now.Add(-1 * time.Duration(now.Hour()-rl.diff) * time.Hour)
-		} else {
-			t = now.Add(time.Duration(rl.diff-now.Hour()) * time.Hour)
+	if rl.curFn != "" {
+		if !rl.rotateRule.ShallRotate(rl.curFn, rl.curSize, now) {
+			return rl.curFn
 		}
-	} else {
-		diff := time.Duration(now.UnixNano()) % rl.rotationTime
-		t = now.Add(time.Duration(-1 * diff))
-	}
-	// 没有设定最大文件大小，按时间切分
-	tmpFileName := rl.pattern.FormatString(t)
-	if rl.maxFileSize <= 0 {
-		return tmpFileName
 	}
 
-	// 按文件切分，但每分钟只检测一次，尚未到下一次检测时间
-	if time.Now().Unix()-rl.lastCheckTime < checkInterval {
-		if rl.curFn == "" {
-			return genNameByTime(tmpFileName)
-		}
-		return rl.curFn
-	}
-	return rl.genFileNameWithSizeLimit(rl.curFn)
+	return rl.rotateRule.BackupFileName(rl.pattern.FormatString(now), now)
 }
 
 // Write satisfies the io.Writer interface. It writes to the
 // appropriate file handle that is currently being used.
 // If we have reached rotation time, the target file gets
 // automatically rotated, and also purged if necessary.
+//
+// If WithAsync was used, Write only copies p onto a queue serviced
+// by a background goroutine and does no file I/O itself; once the
+// queue is full, Write waits until the background goroutine takes
+// the next entry off it. Use Sync to wait for queued writes to
+// actually reach disk.
 func (rl *RotateLogs) Write(p []byte) (n int, err error) {
+	if rl.async {
+		// shutdownMu is held for reading across the send so that
+		// Close cannot close asyncCh while this goroutine still
+		// intends to send on it; Close takes the write lock and sets
+		// rl.closed before closing the channel, so once closed is
+		// observed to be false here it is guaranteed that
+		// close(asyncCh) has not happened yet and never will for this
+		// send.
+		rl.shutdownMu.RLock()
+		defer rl.shutdownMu.RUnlock()
+		if rl.closed {
+			return 0, errors.New(`rotatelogs: write to closed RotateLogs`)
+		}
+
+		buf := make([]byte, len(p))
+		copy(buf, p)
+		rl.asyncCh <- asyncMsg{data: buf}
+		return len(p), nil
+	}
+
 	// Guard against concurrent writes
 	rl.mutex.Lock()
 	defer rl.mutex.Unlock()
 
+	return rl.writeLocked(p)
+}
+
+// writeLocked performs the actual write to the active file and
+// updates rl.curSize, the in-memory size tracker genFilename and
+// getTargetWriterRenameMode consult instead of stat'ing the active
+// file on every write. Callers must hold rl.mutex.
+func (rl *RotateLogs) writeLocked(p []byte) (int, error) {
 	out, err := rl.getTargetWriter()
 	if err != nil {
 		return 0, errors.Wrap(err, `failed to acquite target io.Writer`)
 	}
 
-	return out.Write(p)
+	n, err := out.Write(p)
+	rl.curSize += int64(n)
+	return n, err
 }
 
 // must be locked during this operation
 func (rl *RotateLogs) getTargetWriter() (io.Writer, error) {
+	if rl.rotateMode == ModeRename {
+		return rl.getTargetWriterRenameMode()
+	}
+
 	// This filename contains the name of the "NEW" filename
 	// to log to, which may be newer than rl.currentFilename
 	filename := rl.genFilename()
@@ -286,19 +267,32 @@ func (rl *RotateLogs) getTargetWriter() (io.Writer, error) {
 	if err != nil {
 		return nil, errors.Errorf("failed to open file %s: %s", rl.pattern, err)
 	}
-	if rl.maxFileSize <= 0 {
-		if err := rl.rotate(filename); err != nil {
-			// Failure to rotate is a problem, but it's really not a great
-			// idea to stop your application just because you couldn't rename
-			// your log. For now, we're just going to punt it and write to
-			// os.Stderr
-			fmt.Fprintf(os.Stderr, "failed to rotate: %s\n", err)
-		}
+	if err := rl.rotate(filename); err != nil {
+		// Failure to rotate is a problem, but it's really not a great
+		// idea to stop your application just because you couldn't rename
+		// your log. For now, we're just going to punt it and write to
+		// os.Stderr
+		fmt.Fprintf(os.Stderr, "failed to rotate: %s\n", err)
 	}
 
+	var size int64
+	if info, statErr := fh.Stat(); statErr == nil {
+		size = info.Size()
+	}
+
+	previousFn := rl.curFn
 	rl.outFh.Close()
 	rl.outFh = fh
 	rl.curFn = filename
+	rl.curSize = size
+
+	if previousFn != "" {
+		rl.fireEvent(FileRotatedEvent{PreviousFile: previousFn, CurrentFile: filename})
+	}
+
+	// previousFn is no longer open for writing, so it's safe to
+	// hand off to the background compression worker.
+	rl.enqueueCompress(previousFn)
 
 	return fh, nil
 }
@@ -316,61 +310,34 @@ var patternConversionRegexps = []*regexp.Regexp{
 	regexp.MustCompile(`\*+`),
 }
 
-type cleanupGuard struct {
-	enable bool
-	fn     func()
-	mutex  sync.Mutex
-}
-
-func (g *cleanupGuard) Enable() {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-	g.enable = true
-}
-func (g *cleanupGuard) Run() {
-	g.fn()
+// rotateCandidate is a file found while globbing for purge
+// candidates in rotate(), along with the stat info needed to decide
+// whether it should be kept.
+type rotateCandidate struct {
+	path      string
+	modTime   time.Time
+	isSymlink bool
 }
 
-func (rl *RotateLogs) rotate(filename string) error {
-	lockfn := filename + `_lock`
-	fh, err := os.OpenFile(lockfn, os.O_CREATE|os.O_EXCL, 0644)
-	if err != nil {
-		// Can't lock, just return
-		return err
-	}
-
-	var guard cleanupGuard
-	guard.fn = func() {
-		fh.Close()
-		os.Remove(lockfn)
-	}
-	defer guard.Run()
-
-	if rl.linkName != "" {
-		tmpLinkName := filename + `_symlink`
-		if err := os.Symlink(filename, tmpLinkName); err != nil {
-			return errors.Wrap(err, `failed to create new symlink`)
-		}
-
-		if err := os.Rename(tmpLinkName, rl.linkName); err != nil {
-			return errors.Wrap(err, `failed to rename new symlink`)
-		}
-	}
-
+// purgeCandidates applies the maxAge/rotationCount trim every purge
+// path in this package uses to matches, returning the subset that
+// should be unlinked, oldest first. exclude, if non-empty, is skipped
+// even if it appears in matches -- the file a caller is currently (or
+// about to be) writing to must never be purged.
+func (rl *RotateLogs) purgeCandidates(matches []string, exclude string) []string {
 	if rl.maxAge <= 0 && rl.rotationCount <= 0 {
-		return errors.New("panic: maxAge and rotationCount are both set")
-	}
-
-	matches, err := filepath.Glob(rl.globPattern)
-	if err != nil {
-		return err
+		return nil
 	}
 
-	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
-	var toUnlink []string
+	// Stat everything up front and sort by mtime, oldest first: matches
+	// aren't necessarily chronological (or in the same order as each
+	// other, when gathered from more than one glob), and the
+	// rotationCount trim below assumes toUnlink is oldest-to-newest so
+	// it can keep the last N entries.
+	var candidates []rotateCandidate
 	for _, path := range matches {
-		// Ignore lock files
-		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
+		// Ignore lock files, and never purge the active file.
+		if path == exclude || strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
 			continue
 		}
 
@@ -384,14 +351,27 @@ func (rl *RotateLogs) rotate(filename string) error {
 			continue
 		}
 
-		if rl.maxAge > 0 && fi.ModTime().After(cutoff) {
+		candidates = append(candidates, rotateCandidate{
+			path:      path,
+			modTime:   fi.ModTime(),
+			isSymlink: fl.Mode()&os.ModeSymlink == os.ModeSymlink,
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].modTime.Before(candidates[j].modTime)
+	})
+
+	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
+	var toUnlink []string
+	for _, c := range candidates {
+		if rl.maxAge > 0 && c.modTime.After(cutoff) {
 			continue
 		}
 
-		if rl.rotationCount > 0 && fl.Mode()&os.ModeSymlink == os.ModeSymlink {
+		if rl.rotationCount > 0 && c.isSymlink {
 			continue
 		}
-		toUnlink = append(toUnlink, path)
+		toUnlink = append(toUnlink, c.path)
 	}
 
 	if rl.rotationCount > 0 {
@@ -403,15 +383,71 @@ func (rl *RotateLogs) rotate(filename string) error {
 		toUnlink = toUnlink[:len(toUnlink)-rl.rotationCount]
 	}
 
+	return toUnlink
+}
+
+// purgeReason names the policy that drove a purgeCandidates call, for
+// the FileRemovedEvent fired per file.
+func (rl *RotateLogs) purgeReason() string {
+	if rl.maxAge > 0 {
+		return "max-age"
+	}
+	return "rotation-count"
+}
+
+func (rl *RotateLogs) rotate(filename string) error {
+	unlock, err := rl.acquireRotateLock(filename)
+	if err != nil {
+		// Can't lock, just return
+		return err
+	}
+	defer unlock()
+
+	if rl.linkName != "" {
+		tmpLinkName := filename + `_symlink`
+		if err := os.Symlink(filename, tmpLinkName); err != nil {
+			return errors.Wrap(err, `failed to create new symlink`)
+		}
+
+		if err := os.Rename(tmpLinkName, rl.linkName); err != nil {
+			return errors.Wrap(err, `failed to rename new symlink`)
+		}
+	}
+
+	if rl.maxAge <= 0 && rl.rotationCount <= 0 {
+		return errors.New("panic: maxAge and rotationCount are both set")
+	}
+
+	matches := rl.effectiveRule().OutdatedFiles(rl.globPattern)
+	// Compressed backups live under the same base name with a
+	// ".gz" suffix tacked on, so unless globPattern ends in a
+	// wildcard they don't match it on their own; glob for them
+	// separately and fold in the ones not already matched, so age
+	// cutoff and rotationCount trimming see each of them once.
+	if rl.compress {
+		seen := make(map[string]struct{}, len(matches))
+		for _, fn := range matches {
+			seen[fn] = struct{}{}
+		}
+		for _, fn := range globOutdatedFiles(rl.globPattern + gzipSuffix) {
+			if _, ok := seen[fn]; !ok {
+				matches = append(matches, fn)
+			}
+		}
+	}
+
+	toUnlink := rl.purgeCandidates(matches, "")
 	if len(toUnlink) <= 0 {
 		return nil
 	}
 
-	guard.Enable()
+	reason := rl.purgeReason()
 	go func() {
 		// unlink files on a separate goroutine
 		for _, path := range toUnlink {
-			os.Remove(path)
+			if err := os.Remove(path); err == nil {
+				rl.fireEvent(FileRemovedEvent{Path: path, Reason: reason})
+			}
 		}
 	}()
 
@@ -421,7 +457,30 @@ func (rl *RotateLogs) rotate(filename string) error {
 // Close satisfies the io.Closer interface. You must
 // call this method if you performed any writes to
 // the object.
+//
+// If WithAsync was used, Close first drains every write still
+// queued before closing the file; if WithCompress was used, it waits
+// for the compression worker to drain too, so neither goroutine
+// leaks past Close.
 func (rl *RotateLogs) Close() error {
+	// Taking shutdownMu for writing blocks until every in-flight
+	// Write/enqueueCompress call currently holding it for reading has
+	// finished its channel send, and rl.closed then stops any new
+	// ones from starting -- so it's safe to close asyncCh/compressCh
+	// right after.
+	rl.shutdownMu.Lock()
+	rl.closed = true
+	rl.shutdownMu.Unlock()
+
+	if rl.async {
+		close(rl.asyncCh)
+		rl.asyncWG.Wait()
+	}
+	if rl.compress {
+		close(rl.compressCh)
+		rl.compressWG.Wait()
+	}
+
 	rl.mutex.Lock()
 	defer rl.mutex.Unlock()
 
@@ -0,0 +1,74 @@
+package rotatelogs
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	strftime "github.com/wlibo666/go-strftime"
+)
+
+// Clock is the interface used by the RotateLogs
+// object to determine the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// clockFn is an implementation of Clock that uses
+// a plain function to return the current time.
+type clockFn func() time.Time
+
+var (
+	// Local is the default Clock implementation, which
+	// returns the current time in the local time zone.
+	Local = clockFn(time.Now)
+	// UTC is a Clock implementation that always returns
+	// the current time in UTC.
+	UTC = clockFn(func() time.Time { return time.Now().UTC() })
+)
+
+// Option is used to pass optional arguments to
+// the RotateLogs constructor.
+type Option interface {
+	Configure(*RotateLogs) error
+}
+
+// OptionFn is a type of Option that is represented
+// by a single function that gets called for the
+// configuration.
+type OptionFn func(*RotateLogs) error
+
+// RotateLogs represents a log file that gets
+// automatically rotated as you write to it.
+type RotateLogs struct {
+	async         bool
+	asyncBufSize  int
+	asyncCh       chan asyncMsg
+	asyncWG       sync.WaitGroup
+	clock         Clock
+	closed        bool
+	compress      bool
+	compressCh    chan string
+	compressWG    sync.WaitGroup
+	curFn         string
+	curSize       int64
+	diff          int
+	eventHandler  Handler
+	globPattern   string
+	linkName      string
+	maxAge        time.Duration
+	maxFileSize   int64
+	multiProcess  bool
+	mutex         sync.RWMutex
+	outFh         *os.File
+	pattern       *strftime.Strftime
+	rotateMode    RotateMode
+	rotateRule    RotateRule
+	rotationCount int
+	rotationTime  time.Duration
+	// shutdownMu guards closed and serializes it against any
+	// in-flight send on asyncCh/compressCh, so Close never closes
+	// either channel while a Write/enqueueCompress call is still
+	// sending on it.
+	shutdownMu sync.RWMutex
+}